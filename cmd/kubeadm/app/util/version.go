@@ -79,13 +79,28 @@ func isStatus404Error(err error) bool {
 //  latest      (latest release, including alpha/beta)
 //  latest-1    (latest release in 1.x, including alpha/beta)
 //  latest-1.0  (and similarly 1.1, 1.2, 1.3, ...)
+//
+// version may also be a semver range constraint, e.g. "^1.15" or
+// ">=1.14.3, <1.15", in which case the highest published stable release
+// satisfying it is returned.
 func KubernetesReleaseVersion(version string) (string, error) {
 	ver := normalizedBuildVersion(version)
 	if len(ver) != 0 {
 		return ver, nil
 	}
 
-	bucketURL, versionLabel, err := splitVersion(version)
+	// version may be a semver range constraint, e.g. "^1.15" or
+	// ">=1.14.3, <1.15"; splitVersion's bucket-prefix regex doesn't allow
+	// the comparator characters those use, so check before splitting.
+	if constraint, constraintErr := parseVersionConstraint(version); constraintErr == nil {
+		body, err := resolveConstraint("release", constraint)
+		if err != nil {
+			return "", err
+		}
+		return KubernetesReleaseVersion(body)
+	}
+
+	urlSuffix, versionLabel, err := splitVersion(version)
 	if err != nil {
 		return "", err
 	}
@@ -98,8 +113,7 @@ func KubernetesReleaseVersion(version string) (string, error) {
 
 	// kubeReleaseLabelRegex matches labels such as: latest, latest-1, latest-1.10
 	if kubeReleaseLabelRegex.MatchString(versionLabel) {
-		url := fmt.Sprintf("%s/%s.txt", bucketURL, versionLabel)
-		body, err := fetchFromURL(url, getReleaseVersionTimeout)
+		body, _, err := versionResolver.Resolve(urlSuffix, versionLabel)
 		if err != nil {
 			if !isStatus404Error(err) {
 				return "", err
@@ -151,7 +165,7 @@ func normalizedBuildVersion(version string) string {
 }
 
 // Internal helper: split version parts,
-// Return base URL and cleaned-up version
+// Return bucket URL suffix (e.g. "release" or "ci") and cleaned-up version
 func splitVersion(version string) (string, string, error) {
 	var urlSuffix string
 	subs := kubeBucketPrefixes.FindAllStringSubmatch(version, 1)
@@ -166,32 +180,51 @@ func splitVersion(version string) (string, string, error) {
 	default:
 		urlSuffix = "release"
 	}
-	url := fmt.Sprintf("%s/%s", kubeReleaseBucketURL, urlSuffix)
-	return url, subs[0][3], nil
+	return urlSuffix, subs[0][3], nil
 }
 
-// Internal helper: return content of URL
-func fetchFromURL(url string, timeout time.Duration) (string, error) {
+// fetchFromURLConditional returns the content of url. If userAgent is
+// non-empty, it is sent as the request's User-Agent header. It also
+// sends If-None-Match/If-Modified-Since validators when etag/lastModified
+// are non-empty, so callers with a cached body can avoid re-downloading
+// it. notModified is true (and body empty) when the server answered 304.
+func fetchFromURLConditional(url string, timeout time.Duration, userAgent, etag, lastModified string) (body, newETag, newLastModified string, notModified bool, err error) {
 	glog.V(2).Infof("fetching Kubernetes version from URL: %s", url)
 	client := &http.Client{Timeout: timeout, Transport: netutil.SetOldTransportDefaults(&http.Transport{})}
-	resp, err := client.Get(url)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return "", fmt.Errorf("unable to get URL %q: %s", url, err.Error())
+		return "", "", "", false, fmt.Errorf("unable to create request for URL %q: %s", url, err.Error())
+	}
+	if userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", "", false, fmt.Errorf("unable to get URL %q: %s", url, err.Error())
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return "", resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"), true, nil
+	}
 	if resp.StatusCode != http.StatusOK {
 		msg := fmt.Sprintf("unable to fetch file. URL: %q, status: %v", url, resp.Status)
 		// do special handling for 404, as this means that the version file is missing on the server
 		if resp.StatusCode == http.StatusNotFound {
-			return "", status404Error{message: msg}
+			return "", "", "", false, status404Error{message: msg}
 		}
-		return "", errors.New(msg)
+		return "", "", "", false, errors.New(msg)
 	}
-	body, err := ioutil.ReadAll(resp.Body)
+	raw, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("unable to read content of URL %q: %s", url, err.Error())
+		return "", "", "", false, fmt.Errorf("unable to read content of URL %q: %s", url, err.Error())
 	}
-	return strings.TrimSpace(string(body)), nil
+	return strings.TrimSpace(string(raw)), resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"), false, nil
 }
 
 // kubeadmVersion returns the version of the client without metadata.
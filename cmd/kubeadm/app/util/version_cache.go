@@ -0,0 +1,143 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// defaultVersionCacheTTL is how long a resolved label stays valid
+// before it is re-checked against the origin, absent a more specific
+// override.
+const defaultVersionCacheTTL = time.Hour
+
+// versionCacheEntry is one resolved label -> semver mapping, persisted
+// so repeated kubeadm invocations don't all hit the network.
+type versionCacheEntry struct {
+	Version      string    `json:"version"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+	// Verified records whether Version already passed
+	// verifyFetchedVersion. A cache hit or 304 revalidation for an entry
+	// that isn't Verified must still be re-verified before use whenever
+	// --verify-release-signature is enabled: the entry may predate the
+	// flag being turned on, or may have been written by another process
+	// (or a restored/shared cache directory) that never verified it.
+	Verified bool `json:"verified,omitempty"`
+}
+
+// versionCache is an on-disk, TTL'd cache of resolved version labels,
+// keyed by the URL they were fetched from. It is safe for concurrent
+// use.
+//
+// The cache is intentionally permissive about I/O errors: a cache that
+// can't be read or written degrades to always fetching from the origin
+// rather than failing kubeadm outright.
+type versionCache struct {
+	path string
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]versionCacheEntry
+	loaded  bool
+}
+
+func defaultVersionCachePath() string {
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		cacheHome = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(cacheHome, "kubeadm", "versions.json")
+}
+
+// versionResolverCache is the process-wide cache used by HTTPResolver.
+// It is a var (rather than a const path) so tests and callers that want
+// a different cache location or TTL can swap it out.
+var versionResolverCache = &versionCache{
+	path: defaultVersionCachePath(),
+	ttl:  defaultVersionCacheTTL,
+}
+
+func (c *versionCache) load() {
+	c.loaded = true
+	c.entries = map[string]versionCacheEntry{}
+	if c.path == "" {
+		return
+	}
+	data, err := ioutil.ReadFile(c.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.V(2).Infof("could not read version cache %q: %v", c.path, err)
+		}
+		return
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		glog.V(2).Infof("could not parse version cache %q: %v", c.path, err)
+		c.entries = map[string]versionCacheEntry{}
+	}
+}
+
+func (c *versionCache) get(key string) (versionCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.loaded {
+		c.load()
+	}
+	entry, ok := c.entries[key]
+	return entry, ok
+}
+
+func (c *versionCache) set(key string, entry versionCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.loaded {
+		c.load()
+	}
+	c.entries[key] = entry
+	if c.path == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		glog.V(2).Infof("could not create version cache directory for %q: %v", c.path, err)
+		return
+	}
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		glog.V(2).Infof("could not marshal version cache: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(c.path, data, 0600); err != nil {
+		glog.V(2).Infof("could not write version cache %q: %v", c.path, err)
+	}
+}
+
+// fresh reports whether entry is still within ttl.
+func (e versionCacheEntry) fresh(ttl time.Duration) bool {
+	return !e.FetchedAt.IsZero() && time.Since(e.FetchedAt) < ttl
+}
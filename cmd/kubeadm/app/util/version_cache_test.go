@@ -0,0 +1,266 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestVersionCacheEntryFresh(t *testing.T) {
+	cases := []struct {
+		name      string
+		fetchedAt time.Time
+		ttl       time.Duration
+		want      bool
+	}{
+		{"zero value is never fresh", time.Time{}, time.Hour, false},
+		{"just fetched", time.Now(), time.Hour, true},
+		{"well within ttl", time.Now().Add(-time.Minute), time.Hour, true},
+		{"expired", time.Now().Add(-2 * time.Hour), time.Hour, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			entry := versionCacheEntry{Version: "v1.15.0", FetchedAt: c.fetchedAt}
+			if got := entry.fresh(c.ttl); got != c.want {
+				t.Errorf("fresh() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestVersionCacheGetSetMiss(t *testing.T) {
+	dir, err := ioutil.TempDir("", "versioncache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &versionCache{path: filepath.Join(dir, "versions.json"), ttl: time.Hour}
+	if _, ok := c.get("https://dl.k8s.io/release/stable.txt"); ok {
+		t.Fatal("expected a miss on an empty cache")
+	}
+}
+
+func TestVersionCacheRoundTripsAcrossInstances(t *testing.T) {
+	dir, err := ioutil.TempDir("", "versioncache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "versions.json")
+	key := "https://dl.k8s.io/release/stable.txt"
+	entry := versionCacheEntry{Version: "v1.15.0", ETag: `"abc"`, FetchedAt: time.Now()}
+
+	first := &versionCache{path: path, ttl: time.Hour}
+	first.set(key, entry)
+
+	second := &versionCache{path: path, ttl: time.Hour}
+	got, ok := second.get(key)
+	if !ok {
+		t.Fatalf("expected %q to be persisted to %q and reloaded", key, path)
+	}
+	if got.Version != entry.Version || got.ETag != entry.ETag {
+		t.Errorf("got %+v, want %+v", got, entry)
+	}
+}
+
+func TestVersionCacheCorruptFileDegradesToMiss(t *testing.T) {
+	dir, err := ioutil.TempDir("", "versioncache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "versions.json")
+	if err := ioutil.WriteFile(path, []byte("not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &versionCache{path: path, ttl: time.Hour}
+	if _, ok := c.get("https://dl.k8s.io/release/stable.txt"); ok {
+		t.Fatal("expected a corrupt cache file to degrade to a miss, not a match")
+	}
+}
+
+// TestHTTPResolverRevalidatesOn304 exercises the full cache path through
+// HTTPResolver.Resolve: a cold fetch populates the cache with an ETag;
+// once the entry has expired, a conditional request that gets a 304
+// must reuse the cached version rather than treating an empty 304 body
+// as the new version, and must extend the cache's freshness so the next
+// call doesn't hit the server at all.
+func TestHTTPResolverRevalidatesOn304(t *testing.T) {
+	const version = "v1.15.3"
+	const etag = `"etag-1"`
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == etag {
+			w.Header().Set("ETag", etag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(version))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "versioncache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldCache := versionResolverCache
+	versionResolverCache = &versionCache{path: filepath.Join(dir, "versions.json"), ttl: time.Hour}
+	defer func() { versionResolverCache = oldCache }()
+
+	resolver := &HTTPResolver{BucketURL: server.URL}
+
+	body, _, err := resolver.Resolve("release", "stable")
+	if err != nil {
+		t.Fatalf("cold fetch: %v", err)
+	}
+	if body != version {
+		t.Fatalf("cold fetch: got %q, want %q", body, version)
+	}
+	if requests != 1 {
+		t.Fatalf("cold fetch: expected 1 request, got %d", requests)
+	}
+
+	// Still within TTL: must not contact the server again.
+	body, _, err = resolver.Resolve("release", "stable")
+	if err != nil {
+		t.Fatalf("cache hit: %v", err)
+	}
+	if body != version || requests != 1 {
+		t.Fatalf("cache hit: got body %q after %d requests, want %q after 1 request", body, requests, version)
+	}
+
+	// Expire the entry so the next Resolve revalidates against the origin.
+	key := server.URL + "/release/stable.txt"
+	entry, ok := versionResolverCache.get(key)
+	if !ok {
+		t.Fatal("expected a cache entry to revalidate")
+	}
+	entry.FetchedAt = time.Now().Add(-2 * time.Hour)
+	versionResolverCache.set(key, entry)
+
+	body, _, err = resolver.Resolve("release", "stable")
+	if err != nil {
+		t.Fatalf("revalidation: %v", err)
+	}
+	if body != version {
+		t.Fatalf("revalidation: 304 should have kept the cached version, got %q", body)
+	}
+	if requests != 2 {
+		t.Fatalf("revalidation: expected a single conditional request, got %d total requests", requests)
+	}
+
+	// The entry's TTL should now be extended: another call within TTL
+	// must not hit the server again.
+	body, _, err = resolver.Resolve("release", "stable")
+	if err != nil {
+		t.Fatalf("post-revalidation cache hit: %v", err)
+	}
+	if body != version || requests != 2 {
+		t.Fatalf("post-revalidation cache hit: got body %q after %d requests, want %q after 2 requests", body, requests, version)
+	}
+}
+
+// fakeSignatureVerifier records how many times it was asked to verify and
+// either always succeeds or always fails, so cache-bypass tests can tell
+// whether HTTPResolver.Resolve actually called it.
+type fakeSignatureVerifier struct {
+	calls int
+	fail  bool
+}
+
+func (f *fakeSignatureVerifier) Verify(url, sigURL string, body []byte) error {
+	f.calls++
+	if f.fail {
+		return fmt.Errorf("fake verification failure")
+	}
+	return nil
+}
+
+// TestHTTPResolverReverifiesUnverifiedCacheEntries guards against a cache
+// hit or 304 revalidation silently trusting an entry that was written
+// before --verify-release-signature was enabled (or by another process):
+// Resolve must re-run verification on first use and must refuse to serve
+// the cached version if that verification fails.
+func TestHTTPResolverReverifiesUnverifiedCacheEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "versioncache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldCache := versionResolverCache
+	versionResolverCache = &versionCache{path: filepath.Join(dir, "versions.json"), ttl: time.Hour}
+	defer func() { versionResolverCache = oldCache }()
+
+	oldEnabled, oldVerifier := verifyReleaseSignatureEnabled, releaseSignatureVerifier
+	verifyReleaseSignatureEnabled = true
+	defer func() {
+		verifyReleaseSignatureEnabled = oldEnabled
+		releaseSignatureVerifier = oldVerifier
+	}()
+
+	bucketURL := "https://dl.k8s.io"
+	key := bucketURL + "/release/stable.txt"
+	resolver := &HTTPResolver{BucketURL: bucketURL}
+
+	t.Run("successful re-verification is cached", func(t *testing.T) {
+		versionResolverCache.set(key, versionCacheEntry{Version: "v1.15.3", FetchedAt: time.Now()})
+		verifier := &fakeSignatureVerifier{}
+		releaseSignatureVerifier = verifier
+
+		body, _, err := resolver.Resolve("release", "stable")
+		if err != nil {
+			t.Fatalf("Resolve: %v", err)
+		}
+		if body != "v1.15.3" {
+			t.Fatalf("Resolve: got %q, want v1.15.3", body)
+		}
+		if verifier.calls != 1 {
+			t.Fatalf("expected the unverified entry to be verified once, got %d calls", verifier.calls)
+		}
+
+		if _, _, err := resolver.Resolve("release", "stable"); err != nil {
+			t.Fatalf("second Resolve: %v", err)
+		}
+		if verifier.calls != 1 {
+			t.Fatalf("expected the now-verified entry to be trusted without re-verifying, got %d calls", verifier.calls)
+		}
+	})
+
+	t.Run("failed re-verification rejects the cached version", func(t *testing.T) {
+		versionResolverCache.set(key, versionCacheEntry{Version: "v1.15.3", FetchedAt: time.Now()})
+		verifier := &fakeSignatureVerifier{fail: true}
+		releaseSignatureVerifier = verifier
+
+		if _, _, err := resolver.Resolve("release", "stable"); err == nil {
+			t.Fatal("expected Resolve to refuse an unverified cache entry that fails verification")
+		}
+	})
+}
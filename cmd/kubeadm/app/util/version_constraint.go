@@ -0,0 +1,309 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	versionutil "k8s.io/kubernetes/pkg/util/version"
+)
+
+// maxOpenEndedMinorSpan bounds how many minors an open-ended constraint
+// (e.g. ">=1.14") will probe, so a typo or an overly permissive
+// constraint can't turn into an unbounded number of requests against
+// the release bucket.
+const maxOpenEndedMinorSpan = 10
+
+// looksLikeVersionConstraint is a cheap pre-check so callers only pay
+// for parseVersionConstraint's regex/semver work on strings that could
+// plausibly be a constraint, e.g. ">=1.14, <1.16" or "^1.15".
+var looksLikeVersionConstraint = regexp.MustCompile(`[<>^~]|,`)
+
+type constraintOp int
+
+const (
+	opGTE constraintOp = iota
+	opGT
+	opLTE
+	opLT
+	opEQ
+)
+
+type constraintClause struct {
+	op      constraintOp
+	version *versionutil.Version
+}
+
+// versionConstraint is a conjunction (AND) of clauses, e.g.
+// ">=1.14.3, <1.15" becomes [gte 1.14.3, lt 1.15.0].
+type versionConstraint []constraintClause
+
+// parseVersionConstraint parses a comma-separated semver range such as
+// ">=1.14.3, <1.15" or "^1.15" into a versionConstraint. It returns an
+// error if s doesn't look like a constraint at all, so callers can fall
+// back to their own "not a recognized version" error message.
+func parseVersionConstraint(s string) (versionConstraint, error) {
+	if !looksLikeVersionConstraint.MatchString(s) {
+		return nil, fmt.Errorf("%q is not a version constraint", s)
+	}
+
+	var constraint versionConstraint
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(part, ">="):
+			v, err := parsePartialSemantic(strings.TrimPrefix(part, ">="))
+			if err != nil {
+				return nil, err
+			}
+			constraint = append(constraint, constraintClause{opGTE, v})
+		case strings.HasPrefix(part, ">"):
+			v, err := parsePartialSemantic(strings.TrimPrefix(part, ">"))
+			if err != nil {
+				return nil, err
+			}
+			constraint = append(constraint, constraintClause{opGT, v})
+		case strings.HasPrefix(part, "<="):
+			v, err := parsePartialSemantic(strings.TrimPrefix(part, "<="))
+			if err != nil {
+				return nil, err
+			}
+			constraint = append(constraint, constraintClause{opLTE, v})
+		case strings.HasPrefix(part, "<"):
+			v, err := parsePartialSemantic(strings.TrimPrefix(part, "<"))
+			if err != nil {
+				return nil, err
+			}
+			constraint = append(constraint, constraintClause{opLT, v})
+		case strings.HasPrefix(part, "^"):
+			// Kubernetes has kept major version 1 for its entire history,
+			// so the npm-style "^1.x allows up to 2.0" caret semantics
+			// would be meaningless here. Instead "^1.15" is treated as
+			// "anywhere in the 1.15 line", i.e. >=1.15.0, <1.16.0.
+			v, err := parsePartialSemantic(strings.TrimPrefix(part, "^"))
+			if err != nil {
+				return nil, err
+			}
+			upper, err := versionutil.ParseSemantic(fmt.Sprintf("v%d.%d.0", v.Major(), v.Minor()+1))
+			if err != nil {
+				return nil, err
+			}
+			constraint = append(constraint, constraintClause{opGTE, v}, constraintClause{opLT, upper})
+		case strings.HasPrefix(part, "~"):
+			v, err := parsePartialSemantic(strings.TrimPrefix(part, "~"))
+			if err != nil {
+				return nil, err
+			}
+			upper, err := versionutil.ParseSemantic(fmt.Sprintf("v%d.%d.0", v.Major(), v.Minor()+1))
+			if err != nil {
+				return nil, err
+			}
+			constraint = append(constraint, constraintClause{opGTE, v}, constraintClause{opLT, upper})
+		default:
+			v, err := parsePartialSemantic(part)
+			if err != nil {
+				return nil, err
+			}
+			constraint = append(constraint, constraintClause{opEQ, v})
+		}
+	}
+	if len(constraint) == 0 {
+		return nil, fmt.Errorf("%q is not a version constraint", s)
+	}
+	return constraint, nil
+}
+
+// parsePartialSemantic parses versions that may omit the patch (and,
+// for caret/tilde ranges, the minor) component, e.g. "1.15" or "1",
+// zero-filling the missing components the way users write ranges.
+func parsePartialSemantic(s string) (*versionutil.Version, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "v")
+	parts := strings.SplitN(s, ".", 3)
+	for len(parts) < 3 {
+		parts = append(parts, "0")
+	}
+	for _, p := range parts {
+		if _, err := strconv.Atoi(p); err != nil {
+			return nil, fmt.Errorf("invalid version %q in constraint: %v", s, err)
+		}
+	}
+	return versionutil.ParseSemantic("v" + strings.Join(parts, "."))
+}
+
+// compareCore compares only the major.minor.patch core of two versions,
+// ignoring pre-release/build metadata: constraint ranges are meant to
+// select among published (non-prerelease) stable releases.
+func compareCore(a, b *versionutil.Version) int {
+	switch {
+	case a.Major() != b.Major():
+		if a.Major() < b.Major() {
+			return -1
+		}
+		return 1
+	case a.Minor() != b.Minor():
+		if a.Minor() < b.Minor() {
+			return -1
+		}
+		return 1
+	case a.Patch() != b.Patch():
+		if a.Patch() < b.Patch() {
+			return -1
+		}
+		return 1
+	default:
+		return 0
+	}
+}
+
+// matches reports whether v satisfies this single clause.
+func (c constraintClause) matches(v *versionutil.Version) bool {
+	cmp := compareCore(v, c.version)
+	switch c.op {
+	case opGTE:
+		return cmp >= 0
+	case opGT:
+		return cmp > 0
+	case opLTE:
+		return cmp <= 0
+	case opLT:
+		return cmp < 0
+	case opEQ:
+		return cmp == 0
+	default:
+		return false
+	}
+}
+
+// satisfies reports whether v satisfies every clause in the constraint.
+func (c versionConstraint) satisfies(v *versionutil.Version) bool {
+	for _, clause := range c {
+		if !clause.matches(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// minorRange returns the inclusive [low, high] minor-version window
+// implied by c, so resolveConstraint knows which stable-1.N labels are
+// worth probing instead of guessing at an unbounded range.
+func (c versionConstraint) minorRange() (low, high int, ok bool) {
+	low, high = -1, -1
+	for _, clause := range c {
+		m := clause.version.Minor()
+		switch clause.op {
+		case opGTE, opEQ:
+			if low == -1 || int(m) > low {
+				low = int(m)
+			}
+		case opGT:
+			if low == -1 || int(m) > low {
+				low = int(m)
+			}
+		}
+		switch clause.op {
+		case opLTE, opEQ:
+			if high == -1 || int(m) < high {
+				high = int(m)
+			}
+		case opLT:
+			// "<1.15" (i.e. "<1.15.0") excludes all of minor 15, so the
+			// usable upper minor is 1.14. But "<1.14.5" only excludes
+			// part of minor 14 - a release like 1.14.4 can still
+			// satisfy it - so that minor must still be probed.
+			upperMinor := int(m)
+			if clause.version.Patch() == 0 {
+				upperMinor--
+			}
+			if upperMinor < 0 {
+				upperMinor = 0
+			}
+			if high == -1 || upperMinor < high {
+				high = upperMinor
+			}
+		}
+	}
+	if low == -1 && high == -1 {
+		return 0, 0, false
+	}
+	if low == -1 {
+		low = high
+	}
+	if high == -1 || high < low {
+		high = low + maxOpenEndedMinorSpan
+	}
+	return low, high, true
+}
+
+// resolveConstraint finds the highest published release under urlSuffix
+// (e.g. "release") that satisfies constraint, by probing stable-1.N
+// labels from the newest candidate minor down to the oldest. Because
+// "stable-1.N" already names the latest patch release in minor N, the
+// first minor (scanning downward) that satisfies the constraint yields
+// the overall best match.
+func resolveConstraint(urlSuffix string, constraint versionConstraint) (string, error) {
+	low, high, ok := constraint.minorRange()
+	if !ok {
+		return "", fmt.Errorf("version constraint has no usable bounds")
+	}
+	var lastErr error
+	for minor := high; minor >= low; minor-- {
+		label := fmt.Sprintf("stable-1.%d", minor)
+		body, _, err := versionResolver.Resolve(urlSuffix, label)
+		if err != nil {
+			if isStatus404Error(err) {
+				continue
+			}
+			lastErr = err
+			continue
+		}
+		v, err := versionutil.ParseSemantic(body)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if constraint.satisfies(v) {
+			return body, nil
+		}
+	}
+	if lastErr != nil {
+		return "", fmt.Errorf("no published release satisfies the version constraint: %v", lastErr)
+	}
+	return "", fmt.Errorf("no published release satisfies the version constraint")
+}
+
+// ResolveConstraint resolves a semver range constraint, such as
+// "^1.15" or ">=1.14.3, <1.15", to the highest matching published
+// release version, e.g. for `kubeadm upgrade apply '>=1.14, <1.16'`.
+//
+// It always resolves against the "release" bucket; CI/ci-cross builds
+// are addressed by exact label or version, not by constraint.
+func ResolveConstraint(constraint string) (string, error) {
+	c, err := parseVersionConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+	return resolveConstraint("release", c)
+}
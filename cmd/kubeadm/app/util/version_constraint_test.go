@@ -0,0 +1,314 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"testing"
+
+	versionutil "k8s.io/kubernetes/pkg/util/version"
+)
+
+func mustParseSemantic(t *testing.T, s string) *versionutil.Version {
+	t.Helper()
+	v, err := versionutil.ParseSemantic(s)
+	if err != nil {
+		t.Fatalf("ParseSemantic(%q): %v", s, err)
+	}
+	return v
+}
+
+func TestParseVersionConstraint(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		wantErr bool
+		// satisfied/unsatisfied are versions the parsed constraint is
+		// expected to accept/reject, proving the operator parsed correctly.
+		satisfied   []string
+		unsatisfied []string
+	}{
+		{
+			name:        "gte",
+			input:       ">=1.14.3",
+			satisfied:   []string{"v1.14.3", "v1.15.0"},
+			unsatisfied: []string{"v1.14.2"},
+		},
+		{
+			name:        "gt",
+			input:       ">1.14.3",
+			satisfied:   []string{"v1.14.4"},
+			unsatisfied: []string{"v1.14.3"},
+		},
+		{
+			name:        "lte",
+			input:       "<=1.15.0",
+			satisfied:   []string{"v1.15.0", "v1.14.9"},
+			unsatisfied: []string{"v1.15.1"},
+		},
+		{
+			name:        "lt",
+			input:       "<1.15",
+			satisfied:   []string{"v1.14.99"},
+			unsatisfied: []string{"v1.15.0"},
+		},
+		{
+			name:        "range",
+			input:       ">=1.14.3, <1.15",
+			satisfied:   []string{"v1.14.3", "v1.14.99"},
+			unsatisfied: []string{"v1.14.2", "v1.15.0"},
+		},
+		{
+			name:        "caret pins the minor line",
+			input:       "^1.15",
+			satisfied:   []string{"v1.15.0", "v1.15.9"},
+			unsatisfied: []string{"v1.14.9", "v1.16.0"},
+		},
+		{
+			name:        "tilde pins the minor line",
+			input:       "~1.15.2",
+			satisfied:   []string{"v1.15.2", "v1.15.9"},
+			unsatisfied: []string{"v1.14.9", "v1.16.0"},
+		},
+		{
+			name:        "exact",
+			input:       "1.15.0, 1.15.0",
+			satisfied:   []string{"v1.15.0"},
+			unsatisfied: []string{"v1.15.1"},
+		},
+		{
+			name:    "plain version is not a constraint",
+			input:   "1.15.0",
+			wantErr: true,
+		},
+		{
+			name:    "label is not a constraint",
+			input:   "stable",
+			wantErr: true,
+		},
+		{
+			name:    "malformed range",
+			input:   ">=not-a-version",
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			constraint, err := parseVersionConstraint(c.input)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("parseVersionConstraint(%q): expected an error, got none", c.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseVersionConstraint(%q): %v", c.input, err)
+			}
+			for _, s := range c.satisfied {
+				if !constraint.satisfies(mustParseSemantic(t, s)) {
+					t.Errorf("constraint %q should be satisfied by %s", c.input, s)
+				}
+			}
+			for _, s := range c.unsatisfied {
+				if constraint.satisfies(mustParseSemantic(t, s)) {
+					t.Errorf("constraint %q should not be satisfied by %s", c.input, s)
+				}
+			}
+		})
+	}
+}
+
+func TestMinorRangeBounds(t *testing.T) {
+	cases := []struct {
+		name            string
+		input           string
+		wantLow         int
+		wantHigh        int
+		wantHighAtLeast int
+	}{
+		{
+			name:     "open-ended upper bound below 1.15 probes only 1.14",
+			input:    "<1.15",
+			wantLow:  14,
+			wantHigh: 14,
+		},
+		{
+			name:     "closed range",
+			input:    ">=1.14, <1.16",
+			wantLow:  14,
+			wantHigh: 15,
+		},
+		{
+			name:            "open-ended lower bound is capped, not unbounded",
+			input:           ">=1.14",
+			wantLow:         14,
+			wantHighAtLeast: 14,
+		},
+		{
+			name:     "upper bound at minor 0 clamps rather than going negative",
+			input:    "<1.0",
+			wantLow:  0,
+			wantHigh: 0,
+		},
+		{
+			// A patch-qualified upper bound only excludes part of its
+			// minor, so that minor must still be probed - unlike a bare
+			// minor bound such as "<1.15" above.
+			name:     "patch-qualified upper bound still probes its own minor",
+			input:    ">=1.14.3, <1.14.5",
+			wantLow:  14,
+			wantHigh: 14,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			constraint, err := parseVersionConstraint(c.input)
+			if err != nil {
+				t.Fatalf("parseVersionConstraint(%q): %v", c.input, err)
+			}
+			low, high, ok := constraint.minorRange()
+			if !ok {
+				t.Fatalf("minorRange() for %q: expected ok=true", c.input)
+			}
+			if low != c.wantLow {
+				t.Errorf("minorRange() low = %d, want %d", low, c.wantLow)
+			}
+			if c.wantHighAtLeast != 0 {
+				if high < c.wantHighAtLeast {
+					t.Errorf("minorRange() high = %d, want >= %d", high, c.wantHighAtLeast)
+				}
+				if high <= low {
+					t.Errorf("minorRange() high = %d must be > low = %d for an open-ended constraint", high, low)
+				}
+			} else if high != c.wantHigh {
+				t.Errorf("minorRange() high = %d, want %d", high, c.wantHigh)
+			}
+		})
+	}
+}
+
+// fakeVersionResolver answers Resolve from a fixed label -> body map and
+// reports which labels were probed, so resolveConstraint's descending
+// scan and 404-skip behavior can be verified directly.
+type fakeVersionResolver struct {
+	byLabel map[string]string
+	probed  []string
+}
+
+func (f *fakeVersionResolver) Resolve(_, versionLabel string) (string, string, error) {
+	f.probed = append(f.probed, versionLabel)
+	body, ok := f.byLabel[versionLabel]
+	if !ok {
+		return "", "", status404Error{message: fmt.Sprintf("no such label %q", versionLabel)}
+	}
+	return body, "", nil
+}
+
+func TestResolveConstraintScansDescendingAndSkips404s(t *testing.T) {
+	fake := &fakeVersionResolver{byLabel: map[string]string{
+		// stable-1.16 lies above the constraint's upper bound and must
+		// not be returned.
+		"stable-1.16": "v1.16.0",
+		"stable-1.14": "v1.14.8",
+		// stable-1.15 deliberately missing: must be skipped as a 404,
+		// not treated as a hard failure.
+	}}
+	old := versionResolver
+	versionResolver = fake
+	defer func() { versionResolver = old }()
+
+	constraint, err := parseVersionConstraint(">=1.14, <=1.15")
+	if err != nil {
+		t.Fatalf("parseVersionConstraint: %v", err)
+	}
+
+	got, err := resolveConstraint("release", constraint)
+	if err != nil {
+		t.Fatalf("resolveConstraint: %v", err)
+	}
+	if got != "v1.14.8" {
+		t.Errorf("resolveConstraint: got %q, want v1.14.8 after skipping the missing 1.15", got)
+	}
+	if len(fake.probed) == 0 || fake.probed[0] != "stable-1.15" {
+		t.Errorf("resolveConstraint: expected the scan to start at the highest minor, probed order was %v", fake.probed)
+	}
+}
+
+func TestResolveConstraintSkipsNonMatchingMinorForHighestMatch(t *testing.T) {
+	fake := &fakeVersionResolver{byLabel: map[string]string{
+		// 1.16 is published but doesn't satisfy "<1.16": it must be
+		// skipped in favor of the highest minor that actually matches.
+		"stable-1.16": "v1.16.0",
+		"stable-1.15": "v1.15.5",
+	}}
+	old := versionResolver
+	versionResolver = fake
+	defer func() { versionResolver = old }()
+
+	constraint, err := parseVersionConstraint(">=1.14, <1.16")
+	if err != nil {
+		t.Fatalf("parseVersionConstraint: %v", err)
+	}
+	got, err := resolveConstraint("release", constraint)
+	if err != nil {
+		t.Fatalf("resolveConstraint: %v", err)
+	}
+	if got != "v1.15.5" {
+		t.Errorf("resolveConstraint: got %q, want v1.15.5", got)
+	}
+}
+
+func TestResolveConstraintProbesMinorExcludedOnlyByPatch(t *testing.T) {
+	fake := &fakeVersionResolver{byLabel: map[string]string{
+		// 1.14.4 satisfies ">=1.14.3, <1.14.5" even though the minor
+		// itself is only partially excluded by the patch-qualified
+		// upper bound; it must still be probed and returned.
+		"stable-1.14": "v1.14.4",
+	}}
+	old := versionResolver
+	versionResolver = fake
+	defer func() { versionResolver = old }()
+
+	constraint, err := parseVersionConstraint(">=1.14.3, <1.14.5")
+	if err != nil {
+		t.Fatalf("parseVersionConstraint: %v", err)
+	}
+	got, err := resolveConstraint("release", constraint)
+	if err != nil {
+		t.Fatalf("resolveConstraint: %v", err)
+	}
+	if got != "v1.14.4" {
+		t.Errorf("resolveConstraint: got %q, want v1.14.4", got)
+	}
+}
+
+func TestResolveConstraintNoMatchReturnsError(t *testing.T) {
+	fake := &fakeVersionResolver{byLabel: map[string]string{
+		"stable-1.14": "v1.14.0",
+	}}
+	old := versionResolver
+	versionResolver = fake
+	defer func() { versionResolver = old }()
+
+	constraint, err := parseVersionConstraint(">=1.20")
+	if err != nil {
+		t.Fatalf("parseVersionConstraint: %v", err)
+	}
+	if _, err := resolveConstraint("release", constraint); err == nil {
+		t.Fatal("resolveConstraint: expected an error when nothing satisfies the constraint")
+	}
+}
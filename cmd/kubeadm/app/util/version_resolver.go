@@ -0,0 +1,279 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// VersionSourceFlag is the name of the kubeadm flag that selects where
+// version labels such as "stable" or "latest" are resolved from.
+const VersionSourceFlag = "version-source"
+
+// VersionResolver fetches the content of a Kubernetes release version
+// label file (e.g. "stable.txt") and returns it together with the URL
+// (or path) it was read from, so callers can log it and, when enabled,
+// verify its signature.
+//
+// urlSuffix is the bucket path segment produced by splitVersion, e.g.
+// "release" or "ci"/"ci-cross". versionLabel is the label itself, e.g.
+// "stable" or "latest-1.10".
+type VersionResolver interface {
+	Resolve(urlSuffix, versionLabel string) (body string, resolvedURL string, err error)
+}
+
+// HTTPResolver resolves version labels against a Kubernetes release
+// bucket over HTTP(S). It is the default VersionResolver and preserves
+// kubeadm's historical behavior of talking to dl.k8s.io.
+type HTTPResolver struct {
+	// BucketURL is the base bucket URL, e.g. "https://dl.k8s.io". Empty
+	// means kubeReleaseBucketURL.
+	BucketURL string
+	// UserAgent, if set, is sent on the HTTP request. Empty means the
+	// Go http.Client default.
+	UserAgent string
+	// Timeout bounds each request. Zero means getReleaseVersionTimeout.
+	Timeout time.Duration
+}
+
+func (r *HTTPResolver) bucketURL() string {
+	if r.BucketURL != "" {
+		return r.BucketURL
+	}
+	return kubeReleaseBucketURL
+}
+
+func (r *HTTPResolver) timeout() time.Duration {
+	if r.Timeout != 0 {
+		return r.Timeout
+	}
+	return getReleaseVersionTimeout
+}
+
+// Resolve implements VersionResolver, serving from versionResolverCache
+// within its TTL and revalidating via ETag/Last-Modified afterward; a
+// 304 response extends the cache entry without re-fetching the body.
+//
+// A cache hit or 304 only skips verifyFetchedVersion when the cached
+// entry was already verified under the current signature-verification
+// setting; otherwise it is verified here before being trusted, so an
+// entry written before --verify-release-signature was enabled (or by
+// another process) can't be used unverified for the life of the cache.
+func (r *HTTPResolver) Resolve(urlSuffix, versionLabel string) (string, string, error) {
+	url := fmt.Sprintf("%s/%s/%s.txt", r.bucketURL(), urlSuffix, versionLabel)
+
+	cached, haveCached := versionResolverCache.get(url)
+	if haveCached && cached.fresh(versionResolverCache.ttl) {
+		if cached.Verified || verifyFetchedVersion(url, []byte(cached.Version)) == nil {
+			if !cached.Verified && verifyReleaseSignatureEnabled {
+				cached.Verified = true
+				versionResolverCache.set(url, cached)
+			}
+			glog.V(2).Infof("using cached Kubernetes version for %s: %s", url, cached.Version)
+			return cached.Version, url, nil
+		}
+		return "", url, fmt.Errorf("refusing to use unverified cached version from %q", url)
+	}
+
+	etag, lastModified := "", ""
+	if haveCached {
+		etag, lastModified = cached.ETag, cached.LastModified
+	}
+	body, newETag, newLastModified, notModified, err := fetchFromURLConditional(url, r.timeout(), r.UserAgent, etag, lastModified)
+	if err != nil {
+		return "", url, err
+	}
+	if notModified {
+		if !cached.Verified {
+			if err := verifyFetchedVersion(url, []byte(cached.Version)); err != nil {
+				return "", url, err
+			}
+			cached.Verified = verifyReleaseSignatureEnabled
+		}
+		cached.ETag, cached.LastModified = newETag, newLastModified
+		cached.FetchedAt = time.Now()
+		versionResolverCache.set(url, cached)
+		return cached.Version, url, nil
+	}
+
+	if err := verifyFetchedVersion(url, []byte(body)); err != nil {
+		return "", url, err
+	}
+	versionResolverCache.set(url, versionCacheEntry{
+		Version:      body,
+		ETag:         newETag,
+		LastModified: newLastModified,
+		FetchedAt:    time.Now(),
+		Verified:     verifyReleaseSignatureEnabled,
+	})
+	return body, url, nil
+}
+
+// MirrorResolver tries mirror bucket URLs in order, backing off
+// exponentially between failed attempts.
+type MirrorResolver struct {
+	// Mirrors is the ordered list of bucket base URLs to try, e.g.
+	// []string{"https://mirror.example.com", "https://dl.k8s.io"}.
+	Mirrors []string
+	// UserAgent is passed through to each mirror's HTTPResolver.
+	UserAgent string
+	// Timeout is passed through to each mirror's HTTPResolver.
+	Timeout time.Duration
+	// Backoff is the initial delay between mirror attempts; it doubles
+	// after each failed mirror. Zero means 1 second.
+	Backoff time.Duration
+}
+
+func (r *MirrorResolver) backoff() time.Duration {
+	if r.Backoff != 0 {
+		return r.Backoff
+	}
+	return time.Second
+}
+
+// Resolve implements VersionResolver.
+func (r *MirrorResolver) Resolve(urlSuffix, versionLabel string) (string, string, error) {
+	if len(r.Mirrors) == 0 {
+		return "", "", fmt.Errorf("no mirrors configured")
+	}
+	wait := r.backoff()
+	var lastErr error
+	for i, mirror := range r.Mirrors {
+		if i > 0 {
+			glog.V(2).Infof("retrying version resolution against next mirror in %s", wait)
+			time.Sleep(wait)
+			wait *= 2
+		}
+		hr := &HTTPResolver{BucketURL: mirror, UserAgent: r.UserAgent, Timeout: r.Timeout}
+		body, url, err := hr.Resolve(urlSuffix, versionLabel)
+		if err == nil {
+			return body, url, nil
+		}
+		glog.Infof("mirror %q failed: %v", mirror, err)
+		lastErr = err
+	}
+	return "", "", fmt.Errorf("all mirrors exhausted, last error: %v", lastErr)
+}
+
+// FileResolver reads version labels from a local directory instead of
+// fetching them over the network, for air-gapped installs.
+type FileResolver struct {
+	// Dir is the directory containing "<versionLabel>.txt" files.
+	Dir string
+}
+
+// Resolve implements VersionResolver. urlSuffix is ignored, since a
+// local directory has no release/ci split.
+func (r *FileResolver) Resolve(_, versionLabel string) (string, string, error) {
+	path := filepath.Join(r.Dir, versionLabel+".txt")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", path, fmt.Errorf("unable to read version label from %q: %v", path, err)
+	}
+	body := strings.TrimSpace(string(data))
+	if err := verifyFetchedVersion(path, []byte(body)); err != nil {
+		return "", path, err
+	}
+	return body, path, nil
+}
+
+// ChainResolver tries each resolver in order and returns the first
+// successful result.
+type ChainResolver []VersionResolver
+
+// Resolve implements VersionResolver.
+func (c ChainResolver) Resolve(urlSuffix, versionLabel string) (string, string, error) {
+	var lastErr error
+	for _, r := range c {
+		body, url, err := r.Resolve(urlSuffix, versionLabel)
+		if err == nil {
+			return body, url, nil
+		}
+		lastErr = err
+	}
+	return "", "", fmt.Errorf("no resolver in chain succeeded, last error: %v", lastErr)
+}
+
+// versionResolver is the VersionResolver used by KubernetesReleaseVersion.
+// It defaults to talking to kubeReleaseBucketURL over HTTP, preserving
+// kubeadm's historical behavior.
+var versionResolver VersionResolver = &HTTPResolver{}
+
+// SetVersionResolver overrides the VersionResolver used by
+// KubernetesReleaseVersion, e.g. to point kubeadm at an internal mirror
+// or an air-gapped file directory.
+func SetVersionResolver(r VersionResolver) {
+	versionResolver = r
+}
+
+// NewVersionResolverFromSource builds a VersionResolver from a
+// --version-source value. source is a comma-separated list of entries, each
+// either a mirror bucket URL or a "file:///path/to/dir" air-gapped
+// directory, e.g.:
+//   - ""                                  the default dl.k8s.io HTTPResolver
+//   - "file:///path/to/dir"               a FileResolver rooted at /path/to/dir
+//   - "https://a, https://b"              a MirrorResolver trying a then b
+//   - "https://a, file:///path/to/dir"    a ChainResolver: mirror a, then the local dir
+func NewVersionResolverFromSource(source string) (VersionResolver, error) {
+	source = strings.TrimSpace(source)
+	if source == "" {
+		return &HTTPResolver{}, nil
+	}
+
+	var mirrors []string
+	var files []VersionResolver
+	for _, part := range strings.Split(source, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if strings.HasPrefix(part, "file://") {
+			files = append(files, &FileResolver{Dir: strings.TrimPrefix(part, "file://")})
+			continue
+		}
+		mirrors = append(mirrors, part)
+	}
+	if len(mirrors) == 0 && len(files) == 0 {
+		return nil, fmt.Errorf("invalid version source %q", source)
+	}
+
+	var httpPart VersionResolver
+	switch len(mirrors) {
+	case 0:
+	case 1:
+		httpPart = &HTTPResolver{BucketURL: mirrors[0]}
+	default:
+		httpPart = &MirrorResolver{Mirrors: mirrors}
+	}
+
+	switch {
+	case httpPart != nil && len(files) > 0:
+		return append(ChainResolver{httpPart}, files...), nil
+	case httpPart != nil:
+		return httpPart, nil
+	case len(files) == 1:
+		return files[0], nil
+	default:
+		return ChainResolver(files), nil
+	}
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T, version string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(version))
+	}))
+}
+
+func newTestCacheDir(t *testing.T) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "versioncache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	old := versionResolverCache
+	versionResolverCache = &versionCache{path: filepath.Join(dir, "versions.json"), ttl: time.Hour}
+	t.Cleanup(func() { versionResolverCache = old })
+}
+
+func TestMirrorResolverFallsThroughToNextMirror(t *testing.T) {
+	newTestCacheDir(t)
+
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer bad.Close()
+	good := newTestServer(t, "v1.15.3")
+	defer good.Close()
+
+	r := &MirrorResolver{Mirrors: []string{bad.URL, good.URL}, Backoff: time.Millisecond}
+	body, url, err := r.Resolve("release", "stable")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if body != "v1.15.3" {
+		t.Errorf("Resolve: got body %q, want v1.15.3", body)
+	}
+	if url != good.URL+"/release/stable.txt" {
+		t.Errorf("Resolve: got url %q, want it to resolve against the good mirror", url)
+	}
+}
+
+func TestMirrorResolverAllFail(t *testing.T) {
+	newTestCacheDir(t)
+
+	bad1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer bad1.Close()
+	bad2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}))
+	defer bad2.Close()
+
+	r := &MirrorResolver{Mirrors: []string{bad1.URL, bad2.URL}, Backoff: time.Millisecond}
+	if _, _, err := r.Resolve("release", "stable"); err == nil {
+		t.Fatal("Resolve: expected an error when every mirror fails")
+	}
+}
+
+func TestFileResolver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileresolver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "stable.txt"), []byte("v1.15.3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &FileResolver{Dir: dir}
+	body, _, err := r.Resolve("release", "stable")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if body != "v1.15.3" {
+		t.Errorf("Resolve: got %q, want v1.15.3", body)
+	}
+
+	if _, _, err := r.Resolve("release", "latest"); err == nil {
+		t.Fatal("Resolve: expected an error for a missing label file")
+	}
+}
+
+func TestChainResolverTriesEachInOrder(t *testing.T) {
+	first := &fakeVersionResolver{byLabel: map[string]string{}}
+	second := &fakeVersionResolver{byLabel: map[string]string{"stable": "v1.15.3"}}
+
+	c := ChainResolver{first, second}
+	body, _, err := c.Resolve("release", "stable")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if body != "v1.15.3" {
+		t.Errorf("Resolve: got %q, want v1.15.3", body)
+	}
+	if len(first.probed) != 1 || len(second.probed) != 1 {
+		t.Errorf("Resolve: expected both resolvers in the chain to be tried, probed %v / %v", first.probed, second.probed)
+	}
+}
+
+func TestChainResolverAllFail(t *testing.T) {
+	first := &fakeVersionResolver{byLabel: map[string]string{}}
+	second := &fakeVersionResolver{byLabel: map[string]string{}}
+
+	c := ChainResolver{first, second}
+	if _, _, err := c.Resolve("release", "stable"); err == nil {
+		t.Fatal("Resolve: expected an error when every resolver in the chain fails")
+	}
+}
+
+func TestNewVersionResolverFromSource(t *testing.T) {
+	cases := []struct {
+		name   string
+		source string
+		want   string // "%T" of the returned resolver
+	}{
+		{"empty source is the default HTTP resolver", "", "*util.HTTPResolver"},
+		{"single mirror is a plain HTTP resolver", "https://a.example.com", "*util.HTTPResolver"},
+		{"multiple mirrors compose a MirrorResolver", "https://a.example.com, https://b.example.com", "*util.MirrorResolver"},
+		{"a single file:// source is a plain FileResolver", "file:///tmp/dir", "*util.FileResolver"},
+		{"multiple file:// sources compose a ChainResolver", "file:///a, file:///b", "util.ChainResolver"},
+		{"mirrors plus a file:// fallback compose a ChainResolver", "https://a.example.com, file:///tmp/dir", "util.ChainResolver"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := NewVersionResolverFromSource(c.source)
+			if err != nil {
+				t.Fatalf("NewVersionResolverFromSource(%q): %v", c.source, err)
+			}
+			if got := typeName(r); got != c.want {
+				t.Errorf("NewVersionResolverFromSource(%q): got %s, want %s", c.source, got, c.want)
+			}
+		})
+	}
+}
+
+func TestNewVersionResolverFromSourceInvalid(t *testing.T) {
+	if _, err := NewVersionResolverFromSource(",  ,"); err == nil {
+		t.Fatal("NewVersionResolverFromSource: expected an error for a source with no usable entries")
+	}
+}
+
+func typeName(r VersionResolver) string {
+	switch r.(type) {
+	case *HTTPResolver:
+		return "*util.HTTPResolver"
+	case *MirrorResolver:
+		return "*util.MirrorResolver"
+	case *FileResolver:
+		return "*util.FileResolver"
+	case ChainResolver:
+		return "util.ChainResolver"
+	default:
+		return "unknown"
+	}
+}
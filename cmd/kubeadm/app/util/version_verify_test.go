@@ -0,0 +1,128 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"fmt"
+	"testing"
+)
+
+// The fixtures below are a detached signature over the literal body
+// "v1.15.3", generated once offline; they exist only to exercise
+// CosignVerifier.Verify against real ECDSA/Ed25519 key material rather
+// than a mock.
+const (
+	testSignedBody = "v1.15.3"
+
+	testECDSAPublicKeyPEM = `-----BEGIN PUBLIC KEY-----
+MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEBv3CsICGZP6IXDeFUlrE52guQanm
+McZPgfgmV1DkokACuTmK1KXQDFZ1cVrR6JLRHIcDXIzh0meL1446ptDI/w==
+-----END PUBLIC KEY-----
+`
+	testECDSASignature = "MEUCIQD19kZ9JS5q+b+pnr1kSJzKzBKG45NpJHfr24bdi5bgEwIgSAbHrCqA4GlME0BMPhkkWYXB01NrrpPVhq9LcypK/Vs="
+
+	testWrongECDSAPublicKeyPEM = `-----BEGIN PUBLIC KEY-----
+MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEqHyTPwSTeSE1KuQ+4/YW/MSSEgH/
+UQsKuyvpbTmSYLyBaAiK8UWdtyoZsgZJnwY/kHAF+ZOfK8sPIACxSigWyQ==
+-----END PUBLIC KEY-----
+`
+
+	testEd25519PublicKeyPEM = `-----BEGIN PUBLIC KEY-----
+MCowBQYDK2VwAyEAqowmpTRNIGzQwC8KXFqMo1qhXbkJO8Pz2ExoLhqUBhg=
+-----END PUBLIC KEY-----
+`
+	testEd25519Signature = "8GY692Fceer/6UKQlImrECsV8DURH2kUJu8tQEXpg/ul88A4/UWpOiQS7bvZwuGpgJ4zu1EAIPNxsXgN3fPYBQ=="
+)
+
+func fixedFetchSignature(sig string) func(string) ([]byte, error) {
+	return func(string) ([]byte, error) {
+		return []byte(sig), nil
+	}
+}
+
+func TestCosignVerifierVerify(t *testing.T) {
+	cases := []struct {
+		name       string
+		publicKeys []string
+		sig        string
+		wantErr    bool
+	}{
+		{
+			name:       "valid ECDSA signature",
+			publicKeys: []string{testECDSAPublicKeyPEM},
+			sig:        testECDSASignature,
+		},
+		{
+			name:       "valid Ed25519 signature",
+			publicKeys: []string{testEd25519PublicKeyPEM},
+			sig:        testEd25519Signature,
+		},
+		{
+			name:       "one of several pinned keys matches",
+			publicKeys: []string{testWrongECDSAPublicKeyPEM, testECDSAPublicKeyPEM},
+			sig:        testECDSASignature,
+		},
+		{
+			name:       "signature does not match the pinned key",
+			publicKeys: []string{testWrongECDSAPublicKeyPEM},
+			sig:        testECDSASignature,
+			wantErr:    true,
+		},
+		{
+			name:       "corrupt base64 signature",
+			publicKeys: []string{testECDSAPublicKeyPEM},
+			sig:        "not-valid-base64!!!",
+			wantErr:    true,
+		},
+		{
+			name:       "no public keys pinned",
+			publicKeys: nil,
+			sig:        testECDSASignature,
+			wantErr:    true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			v := &CosignVerifier{
+				PublicKeys:     c.publicKeys,
+				FetchSignature: fixedFetchSignature(c.sig),
+			}
+			err := v.Verify("https://dl.k8s.io/release/stable.txt", "https://dl.k8s.io/release/stable.txt.sig", []byte(testSignedBody))
+			if c.wantErr {
+				if err == nil {
+					t.Fatal("Verify: expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestCosignVerifierVerifyFetchSignatureError(t *testing.T) {
+	v := &CosignVerifier{
+		PublicKeys: []string{testECDSAPublicKeyPEM},
+		FetchSignature: func(string) ([]byte, error) {
+			return nil, fmt.Errorf("network unreachable")
+		},
+	}
+	if err := v.Verify("https://dl.k8s.io/release/stable.txt", "https://dl.k8s.io/release/stable.txt.sig", []byte(testSignedBody)); err == nil {
+		t.Fatal("Verify: expected an error when fetching the signature fails")
+	}
+}